@@ -0,0 +1,197 @@
+package bloomgateway
+
+import (
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	metricsNamespace = "loki"
+	metricsSubsystem = "bloom_gateway"
+)
+
+// DurationBucketsMs is the wide sub-ms to tens-of-seconds distribution
+// shared by every latency histogram in the bloom gateway, including
+// statsaggregator's latencySketch, so that p50/p95/p99 are usable without
+// reconfiguration and the two don't silently drift apart.
+var DurationBucketsMs = []float64{
+	0.01, 0.05, 0.1, 0.3, 0.6, 0.8, 1, 2, 3, 4, 5, 6, 8, 10, 13, 16, 20, 25, 30, 40, 50, 65, 80,
+	100, 130, 160, 200, 250, 300, 400, 500, 650, 800, 1000, 2000, 3000, 4000, 5000, 7500, 10000,
+	20000, 50000, 100000,
+}
+
+// durationBucketsSeconds is DurationBucketsMs converted to seconds, the
+// Prometheus base unit.
+var durationBucketsSeconds = millisecondsToSeconds(DurationBucketsMs)
+
+func millisecondsToSeconds(ms []float64) []float64 {
+	s := make([]float64, len(ms))
+	for i, v := range ms {
+		s[i] = v / 1000
+	}
+	return s
+}
+
+// statsMetrics holds the Prometheus collectors used to export Stats. It is
+// created, and its collectors registered, by NewReporter.
+type statsMetrics struct {
+	queueTime           *prometheus.HistogramVec
+	blocksFetchTime     *prometheus.HistogramVec
+	processingTime      *prometheus.HistogramVec
+	postProcessingTime  *prometheus.HistogramVec
+	totalProcessingTime *prometheus.HistogramVec
+	requestDuration     *prometheus.HistogramVec
+	filterRatio         *prometheus.HistogramVec
+
+	chunksRequested      *prometheus.CounterVec
+	chunksFiltered       *prometheus.CounterVec
+	seriesRequested      *prometheus.CounterVec
+	seriesFiltered       *prometheus.CounterVec
+	skippedBlocks        *prometheus.CounterVec
+	processedBlocks      *prometheus.CounterVec
+	processedBlocksTotal *prometheus.CounterVec
+
+	statsInvalidTotal *prometheus.CounterVec
+}
+
+func newStatsMetrics(reg prometheus.Registerer) *statsMetrics {
+	labels := []string{"status", "tenant"}
+
+	histogram := func(name, help string) *prometheus.HistogramVec {
+		return promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      name,
+			Help:      help,
+			Buckets:   durationBucketsSeconds,
+		}, labels)
+	}
+
+	counter := func(name, help string) *prometheus.CounterVec {
+		return promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      name,
+			Help:      help,
+		}, labels)
+	}
+
+	return &statsMetrics{
+		queueTime:           histogram("queue_time_seconds", "Time spent waiting in queue before being processed."),
+		blocksFetchTime:     histogram("blocks_fetch_time_seconds", "Time spent fetching blocks needed to process a request."),
+		processingTime:      histogram("processing_time_seconds", "Time spent processing a request, excluding queueing and block fetching."),
+		postProcessingTime:  histogram("post_processing_time_seconds", "Time spent post-processing the results of a request."),
+		totalProcessingTime: histogram("total_processing_time_seconds", "Total processing time of a request, summed across all tasks it was split into."),
+		requestDuration:     histogram("request_duration_seconds", "Total duration of a request, summing queue, block fetch, processing and post-processing time."),
+		filterRatio: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "filter_ratio",
+			Help:      "Ratio of chunks filtered out to chunks requested for a request.",
+			Buckets:   prometheus.LinearBuckets(0, 0.1, 11),
+		}, labels),
+
+		chunksRequested:      counter("chunks_requested_total", "Total number of chunks requested."),
+		chunksFiltered:       counter("chunks_filtered_total", "Total number of chunks filtered out."),
+		seriesRequested:      counter("series_requested_total", "Total number of series requested."),
+		seriesFiltered:       counter("series_filtered_total", "Total number of series filtered out."),
+		skippedBlocks:        counter("skipped_blocks_total", "Total number of blocks skipped because they were not available yet."),
+		processedBlocks:      counter("processed_blocks_total", "Total number of blocks processed for a single request."),
+		processedBlocksTotal: counter("processed_blocks_multiplexed_total", "Total number of blocks processed across multiplexed requests."),
+
+		statsInvalidTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "stats_invalid_total",
+			Help:      "Total number of requests whose Stats had a phase that never recorded a duration despite doing work.",
+		}, []string{"phase"}),
+	}
+}
+
+// observe records all metrics derived from s. It is a no-op on a nil
+// receiver.
+func (m *statsMetrics) observe(s *Stats) {
+	if m == nil || s == nil {
+		return
+	}
+
+	labels := []string{s.Status, s.Tenant}
+
+	m.queueTime.WithLabelValues(labels...).Observe(time.Duration(s.QueueTime.Load()).Seconds())
+	m.blocksFetchTime.WithLabelValues(labels...).Observe(time.Duration(s.BlocksFetchTime.Load()).Seconds())
+	m.processingTime.WithLabelValues(labels...).Observe(time.Duration(s.ProcessingTime.Load()).Seconds())
+	m.postProcessingTime.WithLabelValues(labels...).Observe(time.Duration(s.PostProcessingTime.Load()).Seconds())
+	m.totalProcessingTime.WithLabelValues(labels...).Observe(time.Duration(s.TotalProcessingTime.Load()).Seconds())
+	m.requestDuration.WithLabelValues(labels...).Observe(s.Duration().Seconds())
+
+	filterRatio := float64(s.ChunksFiltered) / float64(max(s.ChunksRequested, 1))
+	m.filterRatio.WithLabelValues(labels...).Observe(filterRatio)
+
+	m.chunksRequested.WithLabelValues(labels...).Add(float64(s.ChunksRequested))
+	m.chunksFiltered.WithLabelValues(labels...).Add(float64(s.ChunksFiltered))
+	m.seriesRequested.WithLabelValues(labels...).Add(float64(s.SeriesRequested))
+	m.seriesFiltered.WithLabelValues(labels...).Add(float64(s.SeriesFiltered))
+	m.skippedBlocks.WithLabelValues(labels...).Add(float64(s.SkippedBlocks.Load()))
+	m.processedBlocks.WithLabelValues(labels...).Add(float64(s.ProcessedBlocks.Load()))
+	m.processedBlocksTotal.WithLabelValues(labels...).Add(float64(s.ProcessedBlocksTotal.Load()))
+}
+
+// incInvalid records that phase never recorded a duration for a request that
+// did work. It is a no-op on a nil receiver.
+func (m *statsMetrics) incInvalid(phase string) {
+	if m == nil {
+		return
+	}
+	m.statsInvalidTotal.WithLabelValues(phase).Inc()
+}
+
+// Reporter bundles the dependencies a Stats uses to report itself: the
+// Prometheus collectors Stats.flush observes into, and the logger
+// Stats.Finalize warns through. Construct one with NewReporter per gateway
+// instance (or per test) and pass it into ContextWithEmptyStats /
+// ContextWithTracedStats, rather than relying on mutable package-level
+// state that concurrently running instances would fight over.
+type Reporter struct {
+	metrics *statsMetrics
+	logger  log.Logger
+}
+
+// NewReporter creates a Reporter, registering its Prometheus collectors
+// with reg.
+func NewReporter(reg prometheus.Registerer, logger log.Logger) *Reporter {
+	return &Reporter{
+		metrics: newStatsMetrics(reg),
+		logger:  logger,
+	}
+}
+
+// observe records the metrics derived from s. It is a no-op on a nil
+// receiver, so a Stats created without a Reporter still works, just without
+// Prometheus export.
+func (r *Reporter) observe(s *Stats) {
+	if r == nil {
+		return
+	}
+	r.metrics.observe(s)
+}
+
+// incInvalid records that phase never recorded a duration for a request
+// that did work. It is a no-op on a nil receiver.
+func (r *Reporter) incInvalid(phase string) {
+	if r == nil {
+		return
+	}
+	r.metrics.incInvalid(phase)
+}
+
+// log returns the Reporter's logger, falling back to a no-op logger if the
+// Reporter or its logger is unset.
+func (r *Reporter) log() log.Logger {
+	if r == nil || r.logger == nil {
+		return log.NewNopLogger()
+	}
+	return r.logger
+}
@@ -0,0 +1,23 @@
+package bloomgateway
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewReporterRegistersIndependently(t *testing.T) {
+	// Two Reporters against two registries must not collide or panic, the
+	// way a single shared package-level collector would have.
+	NewReporter(prometheus.NewRegistry(), nil)
+	NewReporter(prometheus.NewRegistry(), nil)
+}
+
+func TestReporterNilSafe(t *testing.T) {
+	var r *Reporter
+	r.observe(&Stats{})
+	r.incInvalid("queue_time")
+	if r.log() == nil {
+		t.Fatalf("log() on a nil Reporter should fall back to a non-nil logger")
+	}
+}
@@ -2,13 +2,20 @@ package bloomgateway
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/grafana/dskit/tenant"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Stats struct {
 	Status                              string
+	Tenant                              string
 	NumTasks, NumMatchers               int
+	Matchers                            []string // set via AddMatchers; used by statsaggregator for its top-N breakdown
+	matchersMu                          sync.Mutex
 	ChunksRequested, ChunksFiltered     int
 	SeriesRequested, SeriesFiltered     int
 	QueueTime                           *atomic.Int64
@@ -18,16 +25,37 @@ type Stats struct {
 	SkippedBlocks                       *atomic.Int32 // blocks skipped because they were not available (yet)
 	ProcessedBlocks                     *atomic.Int32 // blocks processed for this specific request
 	ProcessedBlocksTotal                *atomic.Int32 // blocks processed for multiplexed request
+
+	span     trace.Span // root span started by ContextWithTracedStats, if any
+	reporter *Reporter  // metrics/logger dependencies, set by ContextWithEmptyStats
+
+	// set by Finalize; true when the corresponding phase never recorded a
+	// duration despite the request having tasks or chunks
+	queueTimeMissing          bool
+	blocksFetchTimeMissing    bool
+	processingTimeMissing     bool
+	postProcessingTimeMissing bool
+
+	flushed   atomic.Bool // set by flush, so its one-time side effects run at most once
+	finalized atomic.Bool // set by Finalize, so its one-time side effects run at most once
 }
 
 type statsKey int
 
 var ctxKey = statsKey(0)
 
-// ContextWithEmptyStats returns a context with empty stats.
-func ContextWithEmptyStats(ctx context.Context) (*Stats, context.Context) {
+// ContextWithEmptyStats returns a context with empty stats. reporter supplies
+// the Prometheus collectors and logger Stats reports through; it may be nil,
+// in which case Stats works as before but reports nothing.
+func ContextWithEmptyStats(ctx context.Context, reporter *Reporter) (*Stats, context.Context) {
+	tenantID, _ := tenant.TenantID(ctx)
+	if tenantID == "" {
+		tenantID = "unknown"
+	}
 	stats := &Stats{
 		Status:               "unknown",
+		Tenant:               tenantID,
+		reporter:             reporter,
 		SkippedBlocks:        &atomic.Int32{},
 		ProcessedBlocks:      &atomic.Int32{},
 		ProcessedBlocksTotal: &atomic.Int32{},
@@ -60,16 +88,34 @@ func (s *Stats) Duration() (dur time.Duration) {
 	return
 }
 
+// flush performs Stats' one-time side effects: validating it, recording it
+// through its Reporter and ending its root OTel span. It is idempotent —
+// only the first call (across any number of callers, concurrently or not)
+// has effect — so that KVArgs stays safe to call more than once for the same
+// Stats, e.g. for a retried log line.
+func (s *Stats) flush() {
+	if !s.flushed.CompareAndSwap(false, true) {
+		return
+	}
+	s.Finalize()
+	s.reporter.observe(s)
+	s.endSpan()
+}
+
 func (s *Stats) KVArgs() []any {
 	if s == nil {
 		return []any{}
 	}
+
+	s.flush()
+
 	chunksRemaining := s.ChunksRequested - s.ChunksFiltered
 	filterRatio := float64(s.ChunksFiltered) / float64(max(s.ChunksRequested, 1))
 
 	return []any{
 		"msg", "stats-report",
 		"status", s.Status,
+		"tenant", s.Tenant,
 		"tasks", s.NumTasks,
 		"matchers", s.NumMatchers,
 		"blocks_skipped", s.SkippedBlocks.Load(),
@@ -86,6 +132,10 @@ func (s *Stats) KVArgs() []any {
 		"processing_time", time.Duration(s.ProcessingTime.Load()),
 		"post_processing_time", time.Duration(s.PostProcessingTime.Load()),
 		"duration", s.Duration(),
+		"queue_time_missing", s.queueTimeMissing,
+		"blocks_fetch_time_missing", s.blocksFetchTimeMissing,
+		"processing_time_missing", s.processingTimeMissing,
+		"post_processing_time_missing", s.postProcessingTimeMissing,
 	}
 }
 
@@ -124,6 +174,18 @@ func (s *Stats) AddPostProcessingTime(t time.Duration) {
 	s.PostProcessingTime.Add(int64(t))
 }
 
+// AddMatchers records the label matchers used by a task, so that consumers
+// such as statsaggregator can break requests down by matcher. Safe to call
+// from multiple tasks of the same multiplexed request concurrently.
+func (s *Stats) AddMatchers(matchers ...string) {
+	if s == nil {
+		return
+	}
+	s.matchersMu.Lock()
+	defer s.matchersMu.Unlock()
+	s.Matchers = append(s.Matchers, matchers...)
+}
+
 func (s *Stats) IncSkippedBlocks() {
 	if s == nil {
 		return
@@ -0,0 +1,92 @@
+package bloomgateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestStatsFlushIsIdempotent(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reporter := NewReporter(reg, nil)
+	stats, _ := ContextWithEmptyStats(context.Background(), reporter)
+	stats.NumTasks = 1
+	stats.AddQueueTime(1)
+	stats.AddBlocksFetchTime(1)
+	stats.AddProcessingTime(1)
+	stats.AddPostProcessingTime(1)
+
+	stats.KVArgs()
+	stats.KVArgs()
+
+	metric := &dto.Metric{}
+	if err := reporter.metrics.requestDuration.WithLabelValues(stats.Status, stats.Tenant).(prometheus.Histogram).Write(metric); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := metric.GetHistogram().GetSampleCount(); got != 1 {
+		t.Fatalf("requestDuration sample count = %d, want 1 (flush must not double-observe)", got)
+	}
+}
+
+func TestStatsFinalizeFlagsMissingPhases(t *testing.T) {
+	stats, _ := ContextWithEmptyStats(context.Background(), nil)
+	stats.NumTasks = 1
+	stats.AddQueueTime(1)
+	// blocks fetch, processing and post-processing times are never recorded
+
+	stats.Finalize()
+
+	if stats.queueTimeMissing {
+		t.Fatalf("queueTimeMissing = true, want false: queue time was recorded")
+	}
+	if !stats.blocksFetchTimeMissing || !stats.processingTimeMissing || !stats.postProcessingTimeMissing {
+		t.Fatalf("expected all three unrecorded phases to be flagged missing")
+	}
+}
+
+func TestStatsFinalizeNoOpWithoutWork(t *testing.T) {
+	stats, _ := ContextWithEmptyStats(context.Background(), nil)
+	stats.Finalize()
+
+	if stats.queueTimeMissing || stats.blocksFetchTimeMissing || stats.processingTimeMissing || stats.postProcessingTimeMissing {
+		t.Fatalf("a Stats with no tasks or chunks should not flag any phase missing")
+	}
+}
+
+func TestStatsFinalizeIsIdempotent(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reporter := NewReporter(reg, nil)
+	stats, _ := ContextWithEmptyStats(context.Background(), reporter)
+	stats.NumTasks = 1 // no phases recorded, so every phase is missing
+
+	stats.Finalize()
+	stats.Finalize()
+
+	got := testutil.ToFloat64(reporter.metrics.statsInvalidTotal.WithLabelValues(string(phaseQueue)))
+	if got != 1 {
+		t.Fatalf("stats_invalid_total{phase=queue_time} = %v, want 1 (Finalize must not double-count)", got)
+	}
+}
+
+func TestStatsKVArgsReportsMissingPhases(t *testing.T) {
+	stats, _ := ContextWithEmptyStats(context.Background(), nil)
+	stats.NumTasks = 1
+
+	args := stats.KVArgs()
+
+	found := false
+	for i := 0; i < len(args)-1; i += 2 {
+		if args[i] == "queue_time_missing" {
+			found = true
+			if args[i+1] != true {
+				t.Fatalf("queue_time_missing = %v, want true", args[i+1])
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("KVArgs did not include queue_time_missing")
+	}
+}
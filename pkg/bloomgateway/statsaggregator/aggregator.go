@@ -0,0 +1,174 @@
+// Package statsaggregator maintains a rolling, in-memory view of
+// bloomgateway.Stats so operators can inspect recent request patterns
+// through an HTTP endpoint without standing up a separate metrics backend.
+package statsaggregator
+
+import (
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/loki/v3/pkg/bloomgateway"
+)
+
+// Config configures the Aggregator.
+type Config struct {
+	Enabled         bool          `yaml:"enabled"`
+	HourlyRetention time.Duration `yaml:"hourly_retention"`
+	DailyRetention  time.Duration `yaml:"daily_retention"`
+	PersistPath     string        `yaml:"persist_path"`
+}
+
+// RegisterFlagsWithPrefix registers flags for the aggregator config, adding
+// prefix to each flag name.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "Whether to aggregate bloom gateway request stats and expose them via the stats HTTP endpoint.")
+	f.DurationVar(&cfg.HourlyRetention, prefix+"hourly-retention", 48*time.Hour, "How long hourly stats buckets are kept before being evicted.")
+	f.DurationVar(&cfg.DailyRetention, prefix+"daily-retention", 30*24*time.Hour, "How long daily stats buckets are kept before being evicted.")
+	f.StringVar(&cfg.PersistPath, prefix+"persist-path", "", "If set, path to a file the aggregator's buckets are written to on shutdown and reloaded from on startup.")
+}
+
+// recordQueueSize bounds how many finalized Stats can be queued for
+// aggregation before Record starts dropping them; aggregation is
+// best-effort and must never block request handling.
+const recordQueueSize = 1024
+
+// evictInterval is how often stale buckets are swept from memory.
+const evictInterval = time.Hour
+
+// Aggregator consumes finalized Stats and maintains rolling per-tenant,
+// per-hour and per-day buckets of them.
+type Aggregator struct {
+	cfg    Config
+	logger log.Logger
+
+	recordCh chan *bloomgateway.Stats
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mtx    sync.RWMutex
+	hourly map[string]map[int64]*bucket // tenant -> bucket start (unix seconds) -> bucket
+	daily  map[string]map[int64]*bucket
+}
+
+// New creates an Aggregator and, if cfg.PersistPath is set and a snapshot
+// exists there, reloads its buckets from it. Callers must call Stop to
+// persist state on shutdown and release the background goroutine.
+func New(cfg Config, logger log.Logger) (*Aggregator, error) {
+	a := &Aggregator{
+		cfg:      cfg,
+		logger:   logger,
+		recordCh: make(chan *bloomgateway.Stats, recordQueueSize),
+		done:     make(chan struct{}),
+		hourly:   map[string]map[int64]*bucket{},
+		daily:    map[string]map[int64]*bucket{},
+	}
+
+	if cfg.PersistPath != "" {
+		if err := a.load(cfg.PersistPath); err != nil {
+			return nil, err
+		}
+	}
+
+	a.wg.Add(1)
+	go a.loop()
+
+	return a, nil
+}
+
+// Record enqueues a finalized Stats for aggregation. It is safe to call
+// from any goroutine and never blocks: if the internal queue is full, the
+// Stats is dropped and a warning is logged.
+func (a *Aggregator) Record(s *bloomgateway.Stats) {
+	if a == nil || s == nil {
+		return
+	}
+	select {
+	case a.recordCh <- s:
+	default:
+		level.Warn(a.logger).Log("msg", "dropping bloom gateway stats, aggregator queue is full")
+	}
+}
+
+func (a *Aggregator) loop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(evictInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case s := <-a.recordCh:
+			a.merge(s)
+		case <-ticker.C:
+			a.evict()
+		case <-a.done:
+			return
+		}
+	}
+}
+
+func (a *Aggregator) merge(s *bloomgateway.Stats) {
+	now := time.Now()
+
+	a.mtx.Lock()
+	hourly := a.bucketFor(a.hourly, s.Tenant, now.Truncate(time.Hour))
+	daily := a.bucketFor(a.daily, s.Tenant, now.Truncate(24*time.Hour))
+	a.mtx.Unlock()
+
+	hourly.merge(s)
+	daily.merge(s)
+}
+
+// bucketFor returns the bucket for tenant starting at start, creating it (and
+// the tenant's bucket map) if necessary. Must be called with a.mtx held.
+func (a *Aggregator) bucketFor(byTenant map[string]map[int64]*bucket, tenant string, start time.Time) *bucket {
+	buckets, ok := byTenant[tenant]
+	if !ok {
+		buckets = map[int64]*bucket{}
+		byTenant[tenant] = buckets
+	}
+	b, ok := buckets[start.Unix()]
+	if !ok {
+		b = newBucket(start)
+		buckets[start.Unix()] = b
+	}
+	return b
+}
+
+func (a *Aggregator) evict() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	evictOlderThan(a.hourly, time.Now().Add(-a.cfg.HourlyRetention))
+	evictOlderThan(a.daily, time.Now().Add(-a.cfg.DailyRetention))
+}
+
+func evictOlderThan(byTenant map[string]map[int64]*bucket, cutoff time.Time) {
+	for tenant, buckets := range byTenant {
+		for start := range buckets {
+			if time.Unix(start, 0).Before(cutoff) {
+				delete(buckets, start)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(byTenant, tenant)
+		}
+	}
+}
+
+// Stop persists the aggregator's buckets (if cfg.PersistPath is set) and
+// stops the background goroutine. It must be called once, during shutdown.
+func (a *Aggregator) Stop() {
+	close(a.done)
+	a.wg.Wait()
+
+	if a.cfg.PersistPath == "" {
+		return
+	}
+	if err := a.persist(a.cfg.PersistPath); err != nil {
+		level.Error(a.logger).Log("msg", "failed to persist bloom gateway stats", "err", err)
+	}
+}
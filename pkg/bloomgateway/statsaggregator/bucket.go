@@ -0,0 +1,206 @@
+package statsaggregator
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/loki/v3/pkg/bloomgateway"
+)
+
+// topMatchers is the number of distinct matchers a bucket keeps a count for.
+const topMatchers = 10
+
+// bucket accumulates the Stats recorded during a single hourly or daily
+// window for one tenant. All fields are guarded by mtx since reads (from the
+// HTTP handler) race with merges (from the aggregator's record loop).
+type bucket struct {
+	mtx   sync.Mutex
+	start time.Time
+
+	numTasks        int64
+	chunksRequested int64
+	chunksFiltered  int64
+	seriesRequested int64
+	seriesFiltered  int64
+	skippedBlocks   int64
+	processedBlocks int64
+
+	queueTime          *latencySketch
+	blocksFetchTime    *latencySketch
+	processingTime     *latencySketch
+	postProcessingTime *latencySketch
+
+	matchers map[string]int64
+}
+
+func newBucket(start time.Time) *bucket {
+	return &bucket{
+		start:              start,
+		queueTime:          newLatencySketch(),
+		blocksFetchTime:    newLatencySketch(),
+		processingTime:     newLatencySketch(),
+		postProcessingTime: newLatencySketch(),
+		matchers:           map[string]int64{},
+	}
+}
+
+// merge folds a finalized Stats into the bucket.
+func (b *bucket) merge(s *bloomgateway.Stats) {
+	b.mtx.Lock()
+	b.numTasks += int64(s.NumTasks)
+	b.chunksRequested += int64(s.ChunksRequested)
+	b.chunksFiltered += int64(s.ChunksFiltered)
+	b.seriesRequested += int64(s.SeriesRequested)
+	b.seriesFiltered += int64(s.SeriesFiltered)
+	b.skippedBlocks += int64(s.SkippedBlocks.Load())
+	b.processedBlocks += int64(s.ProcessedBlocks.Load())
+	for _, m := range s.Matchers {
+		b.matchers[m]++
+	}
+	b.mtx.Unlock()
+
+	b.queueTime.observe(time.Duration(s.QueueTime.Load()))
+	b.blocksFetchTime.observe(time.Duration(s.BlocksFetchTime.Load()))
+	b.processingTime.observe(time.Duration(s.ProcessingTime.Load()))
+	b.postProcessingTime.observe(time.Duration(s.PostProcessingTime.Load()))
+}
+
+// snapshot returns an immutable, JSON-ready copy of the bucket. The raw
+// latency sketches and full matcher counts are kept alongside the JSON
+// summary (unexported, so not serialized) so that mergeBucketSnapshots can
+// combine percentiles and top matchers exactly, rather than re-deriving them
+// from already-summarized means or already-truncated top-N lists.
+func (b *bucket) snapshot() bucketSnapshot {
+	b.mtx.Lock()
+	rawMatchers := make(map[string]int64, len(b.matchers))
+	for m, c := range b.matchers {
+		rawMatchers[m] = c
+	}
+	out := bucketSnapshot{
+		Start:           b.start,
+		NumTasks:        b.numTasks,
+		ChunksRequested: b.chunksRequested,
+		ChunksFiltered:  b.chunksFiltered,
+		SeriesRequested: b.seriesRequested,
+		SeriesFiltered:  b.seriesFiltered,
+		SkippedBlocks:   b.skippedBlocks,
+		ProcessedBlocks: b.processedBlocks,
+		TopMatchers:     topNMatchers(b.matchers, topMatchers),
+		rawMatchers:     rawMatchers,
+	}
+	b.mtx.Unlock()
+
+	out.rawQueueTime = b.queueTime.snapshot()
+	out.rawBlocksFetchTime = b.blocksFetchTime.snapshot()
+	out.rawProcessingTime = b.processingTime.snapshot()
+	out.rawPostProcessingTime = b.postProcessingTime.snapshot()
+
+	out.QueueTime = latencyJSONFrom(out.rawQueueTime)
+	out.BlocksFetchTime = latencyJSONFrom(out.rawBlocksFetchTime)
+	out.ProcessingTime = latencyJSONFrom(out.rawProcessingTime)
+	out.PostProcessingTime = latencyJSONFrom(out.rawPostProcessingTime)
+	return out
+}
+
+func topNMatchers(counts map[string]int64, n int) []matcherCount {
+	top := make([]matcherCount, 0, len(counts))
+	for m, c := range counts {
+		top = append(top, matcherCount{Matcher: m, Count: c})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Matcher < top[j].Matcher
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// bucketSnapshot is the JSON representation of a bucket. Buckets are
+// persisted to disk on shutdown as persistedBucket, not as bucketSnapshot.
+type bucketSnapshot struct {
+	Start           time.Time `json:"start"`
+	NumTasks        int64     `json:"num_tasks"`
+	ChunksRequested int64     `json:"chunks_requested"`
+	ChunksFiltered  int64     `json:"chunks_filtered"`
+	SeriesRequested int64     `json:"series_requested"`
+	SeriesFiltered  int64     `json:"series_filtered"`
+	SkippedBlocks   int64     `json:"skipped_blocks"`
+	ProcessedBlocks int64     `json:"processed_blocks"`
+
+	QueueTime          latencyJSON `json:"queue_time"`
+	BlocksFetchTime    latencyJSON `json:"blocks_fetch_time"`
+	ProcessingTime     latencyJSON `json:"processing_time"`
+	PostProcessingTime latencyJSON `json:"post_processing_time"`
+
+	TopMatchers []matcherCount `json:"top_matchers"`
+
+	rawQueueTime          latencySnapshot
+	rawBlocksFetchTime    latencySnapshot
+	rawProcessingTime     latencySnapshot
+	rawPostProcessingTime latencySnapshot
+	rawMatchers           map[string]int64
+}
+
+type matcherCount struct {
+	Matcher string `json:"matcher"`
+	Count   int64  `json:"count"`
+}
+
+type latencyJSON struct {
+	Count int64         `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+}
+
+func latencyJSONFrom(s latencySnapshot) latencyJSON {
+	return latencyJSON{
+		Count: int64(s.count),
+		Mean:  s.mean(),
+		P50:   s.quantile(0.50),
+		P95:   s.quantile(0.95),
+		P99:   s.quantile(0.99),
+	}
+}
+
+// mergeBucketSnapshots combines per-bucket snapshots into a single totals
+// row, exactly re-deriving percentiles and top matchers from the underlying
+// latency sketches and full per-bucket matcher counts, rather than averaging
+// already-summarized means or summing already-truncated top-N lists (which
+// would under-count a matcher that missed one bucket's top-N but would rank
+// in the combined top-N across buckets).
+func mergeBucketSnapshots(snapshots []bucketSnapshot) bucketSnapshot {
+	total := bucketSnapshot{}
+	matcherTotals := map[string]int64{}
+	var queueTimes, blocksFetchTimes, processingTimes, postProcessingTimes []latencySnapshot
+
+	for _, s := range snapshots {
+		total.NumTasks += s.NumTasks
+		total.ChunksRequested += s.ChunksRequested
+		total.ChunksFiltered += s.ChunksFiltered
+		total.SeriesRequested += s.SeriesRequested
+		total.SeriesFiltered += s.SeriesFiltered
+		total.SkippedBlocks += s.SkippedBlocks
+		total.ProcessedBlocks += s.ProcessedBlocks
+		for m, c := range s.rawMatchers {
+			matcherTotals[m] += c
+		}
+		queueTimes = append(queueTimes, s.rawQueueTime)
+		blocksFetchTimes = append(blocksFetchTimes, s.rawBlocksFetchTime)
+		processingTimes = append(processingTimes, s.rawProcessingTime)
+		postProcessingTimes = append(postProcessingTimes, s.rawPostProcessingTime)
+	}
+
+	total.QueueTime = latencyJSONFrom(mergeLatencySnapshots(queueTimes...))
+	total.BlocksFetchTime = latencyJSONFrom(mergeLatencySnapshots(blocksFetchTimes...))
+	total.ProcessingTime = latencyJSONFrom(mergeLatencySnapshots(processingTimes...))
+	total.PostProcessingTime = latencyJSONFrom(mergeLatencySnapshots(postProcessingTimes...))
+	total.TopMatchers = topNMatchers(matcherTotals, topMatchers)
+	return total
+}
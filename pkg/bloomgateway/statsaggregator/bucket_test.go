@@ -0,0 +1,117 @@
+package statsaggregator
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/loki/v3/pkg/bloomgateway"
+)
+
+func newTestStats(numTasks, chunksRequested int, queueTime time.Duration, matchers ...string) *bloomgateway.Stats {
+	s, _ := bloomgateway.ContextWithEmptyStats(context.Background(), nil)
+	s.NumTasks = numTasks
+	s.ChunksRequested = chunksRequested
+	s.AddQueueTime(queueTime)
+	s.AddMatchers(matchers...)
+	return s
+}
+
+func TestTopNMatchers(t *testing.T) {
+	counts := map[string]int64{
+		`{app="a"}`: 5,
+		`{app="b"}`: 10,
+		`{app="c"}`: 1,
+		`{app="d"}`: 10,
+	}
+
+	top := topNMatchers(counts, 2)
+	if len(top) != 2 {
+		t.Fatalf("len(top) = %d, want 2", len(top))
+	}
+	if top[0].Count != 10 || top[1].Count != 10 {
+		t.Fatalf("top two counts = %d, %d, want 10, 10", top[0].Count, top[1].Count)
+	}
+	// ties broken alphabetically by matcher
+	if top[0].Matcher != `{app="b"}` || top[1].Matcher != `{app="d"}` {
+		t.Fatalf("tie-break order = %q, %q, want {app=\"b\"}, {app=\"d\"}", top[0].Matcher, top[1].Matcher)
+	}
+}
+
+func TestMergeBucketSnapshots(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	a := newBucket(start)
+	a.merge(newTestStats(3, 30, 10*time.Millisecond, `{app="a"}`, `{app="b"}`))
+	a.merge(newTestStats(1, 10, 20*time.Millisecond, `{app="a"}`))
+
+	b := newBucket(start)
+	b.merge(newTestStats(4, 40, 5*time.Second, `{app="a"}`, `{app="c"}`))
+
+	total := mergeBucketSnapshots([]bucketSnapshot{a.snapshot(), b.snapshot()})
+	if total.NumTasks != 8 {
+		t.Fatalf("NumTasks = %d, want 8", total.NumTasks)
+	}
+	if total.ChunksRequested != 80 {
+		t.Fatalf("ChunksRequested = %d, want 80", total.ChunksRequested)
+	}
+
+	// the 5s observation from bucket b must survive re-deriving the
+	// percentile from the merged sketch, not just bucket b's own snapshot.
+	if total.QueueTime.P99 < 4*time.Second {
+		t.Fatalf("merged p99 queue time = %v, want >= 4s", total.QueueTime.P99)
+	}
+
+	// {app="a"} appears in both buckets and must be summed across them, not
+	// just taken from whichever bucket's own top-N list ranked it highest.
+	byMatcher := map[string]int64{}
+	for _, m := range total.TopMatchers {
+		byMatcher[m.Matcher] = m.Count
+	}
+	if byMatcher[`{app="a"}`] != 3 {
+		t.Fatalf(`top matchers count for {app="a"} = %d, want 3`, byMatcher[`{app="a"}`])
+	}
+	if byMatcher[`{app="b"}`] != 1 || byMatcher[`{app="c"}`] != 1 {
+		t.Fatalf("expected {app=\"b\"} and {app=\"c\"} each counted once, got %v", byMatcher)
+	}
+}
+
+func TestMergeBucketSnapshotsCombinesAcrossPerBucketTopNTruncation(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	// bucket c has topMatchers high-count matchers plus one low-count
+	// matcher ("low") that its own snapshot's top-N truncates away.
+	c := newBucket(start)
+	for i := 0; i < topMatchers; i++ {
+		matcher := strconv.Itoa(i)
+		hundred := make([]string, 100)
+		for j := range hundred {
+			hundred[j] = matcher
+		}
+		c.merge(newTestStats(1, 0, 0, hundred...))
+	}
+	c.merge(newTestStats(1, 0, 0, "low"))
+
+	snapC := c.snapshot()
+	for _, m := range snapC.TopMatchers {
+		if m.Matcher == "low" {
+			t.Fatalf("test setup invalid: bucket c's own top-N unexpectedly kept \"low\"")
+		}
+	}
+
+	// bucket d only has "low", so it survives bucket d's own top-N.
+	d := newBucket(start)
+	d.merge(newTestStats(1, 0, 0, "low"))
+
+	total := mergeBucketSnapshots([]bucketSnapshot{snapC, d.snapshot()})
+	var lowCount int64
+	for _, m := range total.TopMatchers {
+		if m.Matcher == "low" {
+			lowCount = m.Count
+		}
+	}
+	if lowCount != 2 {
+		t.Fatalf(`combined count for "low" = %d, want 2 (one from each bucket, even though bucket c's own top-N dropped it)`, lowCount)
+	}
+}
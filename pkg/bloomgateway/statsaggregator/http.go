@@ -0,0 +1,76 @@
+package statsaggregator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/go-kit/log/level"
+)
+
+// Granularity is the bucket size of a GET /bloomgateway/stats request.
+type Granularity string
+
+const (
+	Hourly Granularity = "hours"
+	Daily  Granularity = "days"
+)
+
+// Response is the JSON body returned by GET /bloomgateway/stats.
+type Response struct {
+	Unit    Granularity      `json:"unit"`
+	Tenant  string           `json:"tenant"`
+	Total   bucketSnapshot   `json:"total"`
+	Buckets []bucketSnapshot `json:"buckets"`
+}
+
+// ServeHTTP implements GET /bloomgateway/stats?unit=hours|days&tenant=....
+// The current, still-accumulating bucket is included like any other, so the
+// response always reflects up-to-the-second data.
+func (a *Aggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	unit := Granularity(r.URL.Query().Get("unit"))
+	if unit == "" {
+		unit = Hourly
+	}
+	if unit != Hourly && unit != Daily {
+		http.Error(w, fmt.Sprintf("invalid unit %q, must be %q or %q", unit, Hourly, Daily), http.StatusBadRequest)
+		return
+	}
+
+	tenant := r.URL.Query().Get("tenant")
+	if tenant == "" {
+		http.Error(w, "tenant is required", http.StatusBadRequest)
+		return
+	}
+
+	resp := Response{
+		Unit:    unit,
+		Tenant:  tenant,
+		Buckets: a.buckets(unit, tenant),
+	}
+	resp.Total = mergeBucketSnapshots(resp.Buckets)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(a.logger).Log("msg", "failed to encode bloom gateway stats response", "err", err)
+	}
+}
+
+func (a *Aggregator) buckets(unit Granularity, tenant string) []bucketSnapshot {
+	byTenant := a.hourly
+	if unit == Daily {
+		byTenant = a.daily
+	}
+
+	a.mtx.RLock()
+	buckets := byTenant[tenant]
+	snapshots := make([]bucketSnapshot, 0, len(buckets))
+	for _, b := range buckets {
+		snapshots = append(snapshots, b.snapshot())
+	}
+	a.mtx.RUnlock()
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Start.Before(snapshots[j].Start) })
+	return snapshots
+}
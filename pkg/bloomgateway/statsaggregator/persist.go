@@ -0,0 +1,171 @@
+package statsaggregator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// persistedState is the on-disk representation written by Aggregator.persist
+// and read back by Aggregator.load. It keeps the raw latency sketch counts
+// (unlike bucketSnapshot, which drops them for the HTTP response) so that
+// restored buckets report exact percentiles, not re-derived approximations.
+type persistedState struct {
+	Hourly map[string][]persistedBucket `json:"hourly"`
+	Daily  map[string][]persistedBucket `json:"daily"`
+}
+
+type persistedBucket struct {
+	Start           time.Time        `json:"start"`
+	NumTasks        int64            `json:"num_tasks"`
+	ChunksRequested int64            `json:"chunks_requested"`
+	ChunksFiltered  int64            `json:"chunks_filtered"`
+	SeriesRequested int64            `json:"series_requested"`
+	SeriesFiltered  int64            `json:"series_filtered"`
+	SkippedBlocks   int64            `json:"skipped_blocks"`
+	ProcessedBlocks int64            `json:"processed_blocks"`
+	Matchers        map[string]int64 `json:"matchers"`
+
+	QueueTime          persistedLatency `json:"queue_time"`
+	BlocksFetchTime    persistedLatency `json:"blocks_fetch_time"`
+	ProcessingTime     persistedLatency `json:"processing_time"`
+	PostProcessingTime persistedLatency `json:"post_processing_time"`
+}
+
+type persistedLatency struct {
+	Counts []uint64      `json:"counts"`
+	Sum    time.Duration `json:"sum"`
+	Count  uint64        `json:"count"`
+}
+
+func toPersistedLatency(s latencySnapshot) persistedLatency {
+	return persistedLatency{Counts: s.counts, Sum: s.sum, Count: s.count}
+}
+
+// fromPersistedLatency rebuilds a latencySketch from a persisted one. If
+// Counts doesn't match the current latencyBucketsMs (e.g. the file predates
+// a change to the bucket boundaries, or is truncated/corrupt), the sketch is
+// dropped and a fresh, empty one is returned instead of installing a
+// mismatched slice that would later panic with an out-of-range index in
+// observe/merge.
+func fromPersistedLatency(logger log.Logger, p persistedLatency) *latencySketch {
+	h := newLatencySketch()
+	if len(p.Counts) != len(h.counts) {
+		if len(p.Counts) > 0 {
+			level.Warn(logger).Log(
+				"msg", "dropping persisted latency sketch with an incompatible bucket count",
+				"got", len(p.Counts), "want", len(h.counts),
+			)
+		}
+		return h
+	}
+	h.counts = p.Counts
+	h.sum = p.Sum
+	h.count = p.Count
+	return h
+}
+
+func (a *Aggregator) persist(path string) error {
+	a.mtx.RLock()
+	state := persistedState{
+		Hourly: marshalBuckets(a.hourly),
+		Daily:  marshalBuckets(a.daily),
+	}
+	a.mtx.RUnlock()
+
+	f, err := os.CreateTemp(filepath.Dir(path), "statsaggregator-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := json.NewEncoder(f).Encode(state); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func marshalBuckets(byTenant map[string]map[int64]*bucket) map[string][]persistedBucket {
+	out := make(map[string][]persistedBucket, len(byTenant))
+	for tenant, buckets := range byTenant {
+		list := make([]persistedBucket, 0, len(buckets))
+		for _, b := range buckets {
+			b.mtx.Lock()
+			list = append(list, persistedBucket{
+				Start:              b.start,
+				NumTasks:           b.numTasks,
+				ChunksRequested:    b.chunksRequested,
+				ChunksFiltered:     b.chunksFiltered,
+				SeriesRequested:    b.seriesRequested,
+				SeriesFiltered:     b.seriesFiltered,
+				SkippedBlocks:      b.skippedBlocks,
+				ProcessedBlocks:    b.processedBlocks,
+				Matchers:           b.matchers,
+				QueueTime:          toPersistedLatency(b.queueTime.snapshot()),
+				BlocksFetchTime:    toPersistedLatency(b.blocksFetchTime.snapshot()),
+				ProcessingTime:     toPersistedLatency(b.processingTime.snapshot()),
+				PostProcessingTime: toPersistedLatency(b.postProcessingTime.snapshot()),
+			})
+			b.mtx.Unlock()
+		}
+		out[tenant] = list
+	}
+	return out
+}
+
+func (a *Aggregator) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var state persistedState
+	if err := json.NewDecoder(f).Decode(&state); err != nil {
+		return err
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.hourly = unmarshalBuckets(a.logger, state.Hourly)
+	a.daily = unmarshalBuckets(a.logger, state.Daily)
+	return nil
+}
+
+func unmarshalBuckets(logger log.Logger, persisted map[string][]persistedBucket) map[string]map[int64]*bucket {
+	out := make(map[string]map[int64]*bucket, len(persisted))
+	for tenant, list := range persisted {
+		buckets := make(map[int64]*bucket, len(list))
+		for _, p := range list {
+			b := newBucket(p.Start)
+			b.numTasks = p.NumTasks
+			b.chunksRequested = p.ChunksRequested
+			b.chunksFiltered = p.ChunksFiltered
+			b.seriesRequested = p.SeriesRequested
+			b.seriesFiltered = p.SeriesFiltered
+			b.skippedBlocks = p.SkippedBlocks
+			b.processedBlocks = p.ProcessedBlocks
+			if p.Matchers != nil {
+				b.matchers = p.Matchers
+			}
+			b.queueTime = fromPersistedLatency(logger, p.QueueTime)
+			b.blocksFetchTime = fromPersistedLatency(logger, p.BlocksFetchTime)
+			b.processingTime = fromPersistedLatency(logger, p.ProcessingTime)
+			b.postProcessingTime = fromPersistedLatency(logger, p.PostProcessingTime)
+			buckets[p.Start.Unix()] = b
+		}
+		out[tenant] = buckets
+	}
+	return out
+}
@@ -0,0 +1,28 @@
+package statsaggregator
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func TestFromPersistedLatencyDropsIncompatibleCounts(t *testing.T) {
+	h := fromPersistedLatency(log.NewNopLogger(), persistedLatency{Counts: []uint64{1, 2, 3}, Sum: 10, Count: 3})
+	if len(h.counts) != len(latencyBucketsMs)+1 {
+		t.Fatalf("len(counts) = %d, want %d", len(h.counts), len(latencyBucketsMs)+1)
+	}
+	if h.count != 0 {
+		t.Fatalf("count = %d, want 0 (incompatible sketch should be dropped, not trusted)", h.count)
+	}
+	// must not panic on an observation after recovering from a bad sketch
+	h.observe(0)
+}
+
+func TestFromPersistedLatencyKeepsCompatibleCounts(t *testing.T) {
+	counts := make([]uint64, len(latencyBucketsMs)+1)
+	counts[5] = 7
+	h := fromPersistedLatency(log.NewNopLogger(), persistedLatency{Counts: counts, Sum: 100, Count: 7})
+	if h.count != 7 {
+		t.Fatalf("count = %d, want 7", h.count)
+	}
+}
@@ -0,0 +1,120 @@
+package statsaggregator
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/loki/v3/pkg/bloomgateway"
+)
+
+// latencyBucketsMs are the upper bounds, in milliseconds, of the fixed
+// buckets latencySketch accumulates into. This is the same
+// bloomgateway.DurationBucketsMs used by the Prometheus histograms for the
+// same phases, so the two can't silently drift apart, and memory stays
+// bounded regardless of how many Stats are merged into a bucket.
+var latencyBucketsMs = bloomgateway.DurationBucketsMs
+
+// latencySketch is a fixed-bucket histogram used to estimate percentiles of
+// a phase's latency without keeping every observed sample.
+type latencySketch struct {
+	mtx    sync.Mutex
+	counts []uint64 // counts[i] is the number of observations in (latencyBucketsMs[i-1], latencyBucketsMs[i]], not cumulative; counts[len-1] is the overflow bucket
+	sum    time.Duration
+	count  uint64
+}
+
+func newLatencySketch() *latencySketch {
+	return &latencySketch{counts: make([]uint64, len(latencyBucketsMs)+1)}
+}
+
+func (h *latencySketch) observe(d time.Duration) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.sum += d
+	h.count++
+	idx := sort.SearchFloat64s(latencyBucketsMs, float64(d)/float64(time.Millisecond))
+	h.counts[idx]++
+}
+
+// snapshot returns a point-in-time copy, safe to read from concurrently with
+// further observe calls.
+func (h *latencySketch) snapshot() latencySnapshot {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	return latencySnapshot{counts: counts, sum: h.sum, count: h.count}
+}
+
+// merge folds other into h.
+func (h *latencySketch) merge(other latencySnapshot) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.sum += other.sum
+	h.count += other.count
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+}
+
+// latencySnapshot is an immutable copy of a latencySketch's internal state,
+// used both to merge buckets together and to serve read requests.
+type latencySnapshot struct {
+	counts []uint64
+	sum    time.Duration
+	count  uint64
+}
+
+func mergeLatencySnapshots(snapshots ...latencySnapshot) latencySnapshot {
+	if len(snapshots) == 0 {
+		return latencySnapshot{counts: make([]uint64, len(latencyBucketsMs)+1)}
+	}
+	merged := latencySnapshot{counts: make([]uint64, len(latencyBucketsMs)+1)}
+	for _, s := range snapshots {
+		merged.sum += s.sum
+		merged.count += s.count
+		for i, c := range s.counts {
+			merged.counts[i] += c
+		}
+	}
+	return merged
+}
+
+// quantile estimates the q-th quantile (0 <= q <= 1) by walking the bucket
+// counts and returning the upper bound of the bucket that contains it. This
+// over-estimates within a bucket's width, which is the usual trade-off for a
+// fixed-bucket histogram.
+func (s latencySnapshot) quantile(q float64) time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	// rank is 1-indexed: the q-th quantile of count samples is the
+	// ceil(q*count)-th smallest one, with a floor of 1 so q*count < 1 (e.g.
+	// count == 1 at any quantile) still picks the sole observation instead
+	// of a phantom "0th" one that every bucket satisfies immediately.
+	rank := uint64(math.Ceil(q * float64(s.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var cumulative uint64
+	for i, c := range s.counts {
+		cumulative += c
+		if cumulative >= rank {
+			if i == len(latencyBucketsMs) {
+				// overflow bucket: report the last known bound as a floor
+				return time.Duration(latencyBucketsMs[len(latencyBucketsMs)-1]) * time.Millisecond
+			}
+			return time.Duration(latencyBucketsMs[i]) * time.Millisecond
+		}
+	}
+	return time.Duration(latencyBucketsMs[len(latencyBucketsMs)-1]) * time.Millisecond
+}
+
+func (s latencySnapshot) mean() time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / time.Duration(s.count)
+}
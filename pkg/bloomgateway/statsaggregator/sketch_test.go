@@ -0,0 +1,76 @@
+package statsaggregator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencySketchObserveSubMillisecondPrecision(t *testing.T) {
+	h := newLatencySketch()
+	h.observe(300 * time.Microsecond) // 0.3ms: must not truncate to the 0ms/0.01ms bucket
+
+	snap := h.snapshot()
+	if snap.count != 1 {
+		t.Fatalf("count = %d, want 1", snap.count)
+	}
+
+	var bucketMs float64
+	for i, c := range snap.counts {
+		if c > 0 {
+			bucketMs = latencyBucketsMs[i]
+		}
+	}
+	if bucketMs < 0.3 {
+		t.Fatalf("300us observation landed in the %vms bucket, want >= 0.3ms", bucketMs)
+	}
+}
+
+func TestLatencySketchQuantileSingleSample(t *testing.T) {
+	h := newLatencySketch()
+	h.observe(5 * time.Second)
+	snap := h.snapshot()
+
+	for _, q := range []float64{0.5, 0.95, 0.99} {
+		got := snap.quantile(q)
+		if got < 4*time.Second {
+			t.Errorf("quantile(%v) with a single 5s sample = %v, want ~5s", q, got)
+		}
+	}
+}
+
+func TestLatencySketchQuantileFewSamples(t *testing.T) {
+	h := newLatencySketch()
+	for _, d := range []time.Duration{1 * time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond} {
+		h.observe(d)
+	}
+	snap := h.snapshot()
+
+	// rank = ceil(0.3*3) = 1, i.e. the smallest of the three samples.
+	got := snap.quantile(0.3)
+	if got < time.Millisecond {
+		t.Errorf("quantile(0.3) over {1,2,3}ms = %v, want >= 1ms", got)
+	}
+}
+
+func TestLatencySketchMerge(t *testing.T) {
+	a := newLatencySketch()
+	a.observe(10 * time.Millisecond)
+	b := newLatencySketch()
+	b.observe(20 * time.Millisecond)
+	b.observe(20 * time.Millisecond)
+
+	merged := mergeLatencySnapshots(a.snapshot(), b.snapshot())
+	if merged.count != 3 {
+		t.Fatalf("count = %d, want 3", merged.count)
+	}
+	if merged.sum != 50*time.Millisecond {
+		t.Fatalf("sum = %v, want 50ms", merged.sum)
+	}
+}
+
+func TestLatencySketchQuantileEmpty(t *testing.T) {
+	h := newLatencySketch()
+	if got := h.snapshot().quantile(0.99); got != 0 {
+		t.Fatalf("quantile on an empty sketch = %v, want 0", got)
+	}
+}
@@ -0,0 +1,95 @@
+package bloomgateway
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = otel.Tracer("pkg/bloomgateway")
+
+// spanCloser ends a per-phase span and records its elapsed duration into the
+// matching Stats field. It is returned by the Start* helpers and is always
+// safe to call, even on a nil Stats.
+type spanCloser func()
+
+// ContextWithTracedStats behaves like ContextWithEmptyStats, but additionally
+// starts an OpenTelemetry span named "bloomgateway.Request" and associates it
+// with the returned Stats. The Start* helpers below create per-phase child
+// spans of it, so a caller gets both the numeric Stats fields and a
+// distributed trace without instrumenting every call site twice.
+func ContextWithTracedStats(ctx context.Context, reporter *Reporter) (*Stats, context.Context) {
+	stats, ctx := ContextWithEmptyStats(ctx, reporter)
+	ctx, span := tracer.Start(ctx, "bloomgateway.Request")
+	stats.span = span
+	return stats, ctx
+}
+
+// StartQueue starts a child span for the time spent waiting in queue. The
+// returned closer records the elapsed duration into QueueTime and ends the
+// span; callers should defer it.
+func (s *Stats) StartQueue(ctx context.Context) (context.Context, spanCloser) {
+	return s.startPhase(ctx, "queue", s.AddQueueTime)
+}
+
+// StartBlocksFetch starts a child span for the time spent fetching blocks.
+// The returned closer records the elapsed duration into BlocksFetchTime and
+// ends the span; callers should defer it.
+func (s *Stats) StartBlocksFetch(ctx context.Context) (context.Context, spanCloser) {
+	return s.startPhase(ctx, "blocks_fetch", s.AddBlocksFetchTime)
+}
+
+// StartProcessing starts a child span for the time spent processing. The
+// returned closer records the elapsed duration into ProcessingTime and ends
+// the span; callers should defer it.
+func (s *Stats) StartProcessing(ctx context.Context) (context.Context, spanCloser) {
+	return s.startPhase(ctx, "processing", s.AddProcessingTime)
+}
+
+// StartPostProcessing starts a child span for the time spent
+// post-processing. The returned closer records the elapsed duration into
+// PostProcessingTime and ends the span; callers should defer it.
+func (s *Stats) StartPostProcessing(ctx context.Context) (context.Context, spanCloser) {
+	return s.startPhase(ctx, "post_processing", s.AddPostProcessingTime)
+}
+
+func (s *Stats) startPhase(ctx context.Context, name string, add func(time.Duration)) (context.Context, spanCloser) {
+	if s == nil {
+		return ctx, func() {}
+	}
+	ctx, span := tracer.Start(ctx, "bloomgateway."+name)
+	start := time.Now()
+	return ctx, func() {
+		add(time.Since(start))
+		span.End()
+	}
+}
+
+// endSpan sets attributes derived from the final Stats on the root span and
+// ends it. It is called from flush (via KVArgs), whose side effects run at
+// most once per Stats, so the span is ended only once.
+func (s *Stats) endSpan() {
+	if s == nil || s.span == nil {
+		return
+	}
+	chunksRemaining := s.ChunksRequested - s.ChunksFiltered
+	filterRatio := float64(s.ChunksFiltered) / float64(max(s.ChunksRequested, 1))
+
+	s.span.SetAttributes(
+		attribute.String("status", s.Status),
+		attribute.String("tenant", s.Tenant),
+		attribute.Int("tasks", s.NumTasks),
+		attribute.Int("matchers", s.NumMatchers),
+		attribute.Int("series_requested", s.SeriesRequested),
+		attribute.Int("series_filtered", s.SeriesFiltered),
+		attribute.Int("chunks_requested", s.ChunksRequested),
+		attribute.Int("chunks_filtered", s.ChunksFiltered),
+		attribute.Int("chunks_remaining", chunksRemaining),
+		attribute.Int64("blocks_processed", int64(s.ProcessedBlocks.Load())),
+		attribute.Int64("blocks_skipped", int64(s.SkippedBlocks.Load())),
+		attribute.Float64("filter_ratio", filterRatio),
+	)
+	s.span.End()
+}
@@ -0,0 +1,65 @@
+package bloomgateway
+
+import (
+	"github.com/go-kit/log/level"
+)
+
+// phase identifies one of the timed sections of a request, for validation
+// and for the loki_bloom_gateway_stats_invalid_total counter.
+type phase string
+
+const (
+	phaseQueue          phase = "queue_time"
+	phaseBlocksFetch    phase = "blocks_fetch_time"
+	phaseProcessing     phase = "processing_time"
+	phasePostProcessing phase = "post_processing_time"
+)
+
+// Finalize validates that every phase which should have run recorded a
+// non-zero duration, and must be called before KVArgs is emitted; KVArgs
+// does so itself via flush, so callers don't need to call it directly.
+// A phase whose bucket is still zero despite the request having tasks or
+// chunks indicates a code path that forgot to call the matching Add*Time
+// method (analogous to reporting a Go zero-value time as a start); Finalize
+// increments loki_bloom_gateway_stats_invalid_total for that phase and logs
+// a warning, and KVArgs reports it as "<phase>_missing=true". It is
+// idempotent — only the first call has effect — so that it stays safe to
+// call more than once for the same Stats.
+func (s *Stats) Finalize() {
+	if s == nil {
+		return
+	}
+	if !s.finalized.CompareAndSwap(false, true) {
+		return
+	}
+
+	didWork := s.NumTasks > 0 || s.ChunksRequested > 0
+	if !didWork {
+		return
+	}
+
+	s.queueTimeMissing = s.checkPhaseMissing(phaseQueue, s.QueueTime.Load())
+	s.blocksFetchTimeMissing = s.checkPhaseMissing(phaseBlocksFetch, s.BlocksFetchTime.Load())
+	s.processingTimeMissing = s.checkPhaseMissing(phaseProcessing, s.ProcessingTime.Load())
+	s.postProcessingTimeMissing = s.checkPhaseMissing(phasePostProcessing, s.PostProcessingTime.Load())
+
+	if s.queueTimeMissing || s.blocksFetchTimeMissing || s.processingTimeMissing || s.postProcessingTimeMissing {
+		level.Warn(s.reporter.log()).Log(
+			"msg", "bloom gateway stats phase never recorded a duration",
+			"tenant", s.Tenant,
+			"tasks", s.NumTasks,
+			"queue_time_missing", s.queueTimeMissing,
+			"blocks_fetch_time_missing", s.blocksFetchTimeMissing,
+			"processing_time_missing", s.processingTimeMissing,
+			"post_processing_time_missing", s.postProcessingTimeMissing,
+		)
+	}
+}
+
+func (s *Stats) checkPhaseMissing(p phase, recorded int64) bool {
+	missing := recorded == 0
+	if missing {
+		s.reporter.incInvalid(string(p))
+	}
+	return missing
+}